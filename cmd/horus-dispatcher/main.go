@@ -16,6 +16,9 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	_ "net/http/pprof"
@@ -27,15 +30,22 @@ import (
 	"syscall"
 	"time"
 
+	"horus/config"
 	"horus/dispatcher"
 	"horus/log"
 	"horus/model"
+	"horus/rpc"
 
 	_ "github.com/lib/pq"
+	"github.com/oklog/ulid/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/vma/getopt"
 	"github.com/vma/glog"
 	"github.com/vma/httplogger"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
 )
 
 var (
@@ -65,10 +75,40 @@ var (
 	lockDSN         = getopt.StringLong("lock-dsn", 'C', "", "postgres db DSN to use for advisory locks. Must be different from main DSN.", "url")
 	clusterHosts    = getopt.ListLong("cluster-hosts", 'H', "list of all hosts of the dispatcher cluster", "host1:port1,host2:port2,...")
 	dbMaxSnmpJobs   = getopt.IntLong("db-max-snmp-jobs", 'm', 200, "maximum number of snmp jobs to retrieve from db at each query")
+
+	clusterDiscovery     = getopt.StringLong("cluster-discovery", 0, "static", "cluster host discovery mode: `static` (use --cluster-hosts) or `db` (self-registering dispatchers table)")
+	dispatcherStaleAfter = getopt.IntLong("dispatcher-stale-after", 0, 90, "prune dispatchers db rows not refreshed for longer than this delay", "seconds")
+
+	electionBackend = getopt.StringLong("election-backend", 0, "pg", "master election backend: `pg` (advisory lock) or `etcd` (leader election)")
+	etcdEndpoints   = getopt.ListLong("etcd-endpoints", 0, "etcd cluster endpoints, required when --election-backend=etcd", "host1:port1,host2:port2,...")
+	electionTTL     = getopt.IntLong("election-ttl", 0, 15, "etcd election session lease TTL", "seconds")
+	clusterName     = getopt.StringLong("cluster", 0, "default", "cluster name, namespaces the etcd election key and the dispatchers db rows")
+
+	configFile = getopt.StringLong("config", 0, "", "path to an optional YAML config file for live-reloadable tunables", "file")
+
+	streamTCPListen = getopt.StringLong("stream-tcp-listen", 0, "", "address to listen on for the raw TCP NDJSON report stream forwarder (empty disables it)", "host:port")
+
+	logFormat = getopt.StringLong("log-format", 0, "text", "structured log output format: `text` or `json`")
+
+	grpcPort     = getopt.IntLong("grpc-port", 0, 0, "gRPC API listen port; while set, the device HTTP URIs are served by a hand-written reverse proxy in front of it instead of their own handlers (0 to disable)", "port")
+	grpcTLSCert  = getopt.StringLong("grpc-tls-cert", 0, "", "`cert,key` PEM files to enable TLS on the gRPC server", "cert,key")
+	grpcClientCA = getopt.StringLong("grpc-client-ca", 0, "", "CA file to require and verify client certificates (mTLS) on the gRPC server", "file")
+
+	leaderGauge         prometheus.Gauge
+	electionEventsTotal prometheus.Counter
+	configReloadTotal   *prometheus.CounterVec
+
+	pollIntervalCh      = make(chan time.Duration, 1)
+	pingIntervalCh      = make(chan time.Duration, 1)
+	keepAliveIntervalCh = make(chan time.Duration, 1)
+	unlockIntervalCh    = make(chan time.Duration, 1)
 )
 
+const etcdElectionKeyFmt = "/horus/dispatcher/leader/%s"
+
 func main() {
-	getopt.FlagLong(&dispatcher.MaxLoadDelta, "max-load-delta", 0, "max load delta allowed between agents before `unsticking` a device from its agent")
+	var maxLoadDelta float64
+	getopt.FlagLong(&maxLoadDelta, "max-load-delta", 0, "max load delta allowed between agents before `unsticking` a device from its agent")
 	getopt.SetParameters("")
 	getopt.Parse()
 
@@ -78,8 +118,10 @@ func main() {
 	}
 
 	glog.WithConf(glog.Conf{Verbosity: *debug, LogDir: *logDir, PrintLocation: *debug > 0})
+	log.Configure(log.Options{Format: *logFormat, Level: *debug})
 
 	dispatcher.Revision, dispatcher.Branch, dispatcher.Build = Revision, Branch, Build
+	dispatcher.SetMaxLoadDelta(maxLoadDelta)
 
 	if *showVersion {
 		fmt.Printf("Revision:%s Branch:%s Build:%s\n", Revision, Branch, Build)
@@ -103,6 +145,16 @@ func main() {
 		glog.Exit("ping-batch-count cannot be 0 when db-ping-freq is > 0")
 	}
 
+	switch *electionBackend {
+	case "pg":
+	case "etcd":
+		if len(*etcdEndpoints) == 0 {
+			glog.Exit("etcd-endpoints must be set when election-backend=etcd")
+		}
+	default:
+		glog.Exitf("invalid election-backend %q, must be `pg` or `etcd`", *electionBackend)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM, syscall.SIGPIPE)
@@ -115,15 +167,58 @@ func main() {
 		}
 	}()
 
+	// masterCtx scopes the goroutines that only run while this instance
+	// holds cluster leadership (poller, pinger, keepalive, unlocker). It is
+	// cancelled whenever ctx is (real process shutdown) and, for the etcd
+	// backend, also on its own when leadership is lost, so a demotion stops
+	// master duties without force-killing the HTTP/gRPC/TCP servers or
+	// skipping resignLeadership/ReleaseDB/watcher.Close.
+	masterCtx, cancelMaster := context.WithCancel(ctx)
+	defer cancelMaster()
+
+	// Stop every HandleStream/ServeStreamTCP subscriber (drain then close)
+	// on real process shutdown, rather than leaving them running forever.
+	go func() {
+		<-ctx.Done()
+		dispatcher.ShutdownStreams()
+	}()
+
 	dispatcher.RegisterPromMetrics()
+	dispatcher.RegisterStreamMetrics()
+	registerElectionMetrics(fmt.Sprintf("%s:%d", *localIP, *port))
+	configReloadTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "horus_config_reload_total",
+		Help: "number of config file reloads, by result",
+	}, []string{"result"})
 
 	http.HandleFunc(model.ReportURI, dispatcher.HandleReport)
-	http.HandleFunc(dispatcher.DeviceListURI, dispatcher.HandleDeviceList)
-	http.HandleFunc(dispatcher.DeviceCreateURI, dispatcher.HandleDeviceCreate)
-	http.HandleFunc(dispatcher.DeviceUpdateURI, dispatcher.HandleDeviceUpdate)
-	http.HandleFunc(dispatcher.DeviceUpsertURI, dispatcher.HandleDeviceUpsert)
-	http.HandleFunc(dispatcher.DeviceDeleteURI, dispatcher.HandleDeviceDelete)
+	if *grpcPort > 0 {
+		var tlsConfig *tls.Config
+		if *grpcTLSCert != "" {
+			// Loopback call into our own gRPC server, not a network peer,
+			// so there's no cert to validate against.
+			tlsConfig = &tls.Config{InsecureSkipVerify: true}
+		}
+		gwConn, err := rpc.Dial(fmt.Sprintf("%s:%d", *localIP, *grpcPort), tlsConfig)
+		if err != nil {
+			glog.Exitf("dial grpc gateway: %v", err)
+		}
+		http.HandleFunc(dispatcher.DeviceListURI, rpc.ListDevicesGatewayHandler(gwConn))
+		http.HandleFunc(dispatcher.DeviceCreateURI, rpc.CreateDeviceGatewayHandler(gwConn))
+		http.HandleFunc(dispatcher.DeviceUpdateURI, rpc.UpdateDeviceGatewayHandler(gwConn))
+		http.HandleFunc(dispatcher.DeviceUpsertURI, rpc.UpsertDeviceGatewayHandler(gwConn))
+		http.HandleFunc(dispatcher.DeviceDeleteURI, rpc.DeleteDeviceGatewayHandler(gwConn))
+	} else {
+		http.HandleFunc(dispatcher.DeviceListURI, dispatcher.HandleDeviceList)
+		http.HandleFunc(dispatcher.DeviceCreateURI, dispatcher.HandleDeviceCreate)
+		http.HandleFunc(dispatcher.DeviceUpdateURI, dispatcher.HandleDeviceUpdate)
+		http.HandleFunc(dispatcher.DeviceUpsertURI, dispatcher.HandleDeviceUpsert)
+		http.HandleFunc(dispatcher.DeviceDeleteURI, dispatcher.HandleDeviceDelete)
+	}
 	http.HandleFunc("/r/check", handleCheck)
+	http.HandleFunc("/r/peers", handlePeers)
+	http.HandleFunc("/r/reload", handleReload)
+	http.HandleFunc("/r/stream", dispatcher.HandleStream)
 	http.HandleFunc("/-/debug", handleDebugLevel)
 	http.Handle("/metrics", promhttp.Handler())
 
@@ -133,33 +228,86 @@ func main() {
 		wg.Add(1)
 		log.Debugf("starting report web server on %s:%d", *localIP, *port)
 		logger := httplogger.CommonLogger(log.Writer{})
-		glog.Fatal(http.ListenAndServe(fmt.Sprintf("%s:%d", *localIP, *port), logger(http.DefaultServeMux)))
+		glog.Fatal(http.ListenAndServe(fmt.Sprintf("%s:%d", *localIP, *port), logger(withRequestID(http.DefaultServeMux))))
 		wg.Done()
 	}()
 
-	dispatcher.LocalIP, dispatcher.Port, dispatcher.ClusterHosts = *localIP, *port, *clusterHosts
+	if *streamTCPListen != "" {
+		go func() {
+			wg.Add(1)
+			defer wg.Done()
+			log.Debugf("starting stream tcp forwarder on %s", *streamTCPListen)
+			if err := dispatcher.ServeStreamTCP(ctx, *streamTCPListen); err != nil {
+				glog.Errorf("stream tcp forwarder: %v", err)
+			}
+		}()
+	}
+
+	if *grpcPort > 0 {
+		go func() {
+			wg.Add(1)
+			defer wg.Done()
+			log.Debugf("starting grpc server on %s:%d", *localIP, *grpcPort)
+			opts := rpc.Options{
+				Addr:         fmt.Sprintf("%s:%d", *localIP, *grpcPort),
+				TLSCertFiles: *grpcTLSCert,
+				ClientCAFile: *grpcClientCA,
+			}
+			if err := rpc.Serve(ctx, opts); err != nil {
+				glog.Errorf("grpc server: %v", err)
+			}
+		}()
+	}
+
+	dispatcher.LocalIP, dispatcher.Port = *localIP, *port
+	dispatcher.ClusterHosts.Store(clusterHosts)
 
 	if err := dispatcher.ConnectDB(*dsn, *lockDSN); err != nil {
 		glog.Exitf("connect db: %v", err)
 	}
 	defer dispatcher.ReleaseDB()
 
-	if *lockID > 0 {
-		if err := dispatcher.AcquireLock(ctx, *lockID); err != nil {
-			if strings.Contains(err.Error(), "cancel") {
-				return
-			}
-			glog.Exitf("acquire lock: %v", err)
+	if *clusterDiscovery == "db" {
+		instance := fmt.Sprintf("%s:%d", *localIP, *port)
+		if err := dispatcher.RegisterSelf(instance, *clusterName, Revision); err != nil {
+			glog.Exitf("register dispatcher: %v", err)
+		}
+		hosts, err := dispatcher.LoadClusterFromDB(*keepAliveFreq)
+		if err != nil {
+			glog.Exitf("load cluster from db: %v", err)
 		}
+		dispatcher.ClusterHosts.Store(&hosts)
 	}
 
-	dispatcher.IsMaster = true
+	var resignLeadership func()
+	switch *electionBackend {
+	case "etcd":
+		resign, err := runEtcdElection(ctx, cancelMaster, fmt.Sprintf("%s:%d", *localIP, *port))
+		if err != nil {
+			glog.Exitf("etcd leader election: %v", err)
+		}
+		resignLeadership = resign
+	default:
+		if *lockID > 0 {
+			if err := dispatcher.AcquireLock(ctx, *lockID); err != nil {
+				if strings.Contains(err.Error(), "cancel") {
+					return
+				}
+				glog.Exitf("acquire lock: %v", err)
+			}
+		}
+		dispatcher.IsMaster.Store(true)
+		leaderGauge.Set(1)
+	}
+	if resignLeadership != nil {
+		defer resignLeadership()
+	}
 
 	if err := dispatcher.PrepareQueries(); err != nil {
 		glog.Exitf("prepare queries: %v", err)
 	}
 
-	dispatcher.LoadAvgWindow = time.Duration(*snmpLoadAvgWin) * time.Second
+	dispatcher.LoadAvgWindow.Store(int64(time.Duration(*snmpLoadAvgWin) * time.Second))
 
 	if err := dispatcher.LoadAgents(); err != nil {
 		glog.Exitf("error loading agents: %v", err)
@@ -171,29 +319,71 @@ func main() {
 			keepAliveTick := time.NewTicker(time.Duration(*keepAliveFreq) * time.Second)
 			defer keepAliveTick.Stop()
 			var loops int
-			for range keepAliveTick.C {
+			for {
+				select {
+				case <-masterCtx.Done():
+					if ctx.Err() != nil {
+						log.Debugf("interrupted, exiting")
+						os.Exit(0)
+					}
+					log.Debugf("stepping down from leadership, halting agent checker")
+					return
+				case d := <-keepAliveIntervalCh:
+					keepAliveTick.Reset(d)
+					continue
+				case <-keepAliveTick.C:
+				}
 				loops++
 				if loops%10 == 0 {
 					// reload agents from db every 10 keep-alives
 					dispatcher.LoadAgents()
 				}
 				dispatcher.CheckAgents()
+				if *clusterDiscovery == "db" {
+					instance := fmt.Sprintf("%s:%d", *localIP, *port)
+					if err := dispatcher.RegisterSelf(instance, *clusterName, Revision); err != nil {
+						log.Errorf("refresh dispatcher registration: %v", err)
+					}
+					hosts, err := dispatcher.LoadClusterFromDB(*keepAliveFreq)
+					if err != nil {
+						log.Errorf("reload cluster from db: %v", err)
+						continue
+					}
+					dispatcher.ClusterHosts.Store(&hosts)
+				}
+			}
+		}()
+	}
+
+	if *clusterDiscovery == "db" && *dispatcherStaleAfter > 0 {
+		log.Debug("starting dispatcher pruner goroutine")
+		go func() {
+			pruneTick := time.NewTicker(time.Duration(*dispatcherStaleAfter) * time.Second)
+			defer pruneTick.Stop()
+			for range pruneTick.C {
+				dispatcher.PruneDispatchers(*dispatcherStaleAfter)
 			}
 		}()
 	}
 
 	if *dbSnmpQueryFreq > 0 {
-		dispatcher.MaxSnmpJobs = *dbMaxSnmpJobs
+		dispatcher.MaxSnmpJobs.Store(int64(*dbMaxSnmpJobs))
 		log.Debug("starting poller goroutine")
 		go func() {
 			pollTick := time.NewTicker(time.Duration(*dbSnmpQueryFreq) * time.Second)
 			defer pollTick.Stop()
 			for {
-				dispatcher.SendPollingJobs(ctx)
+				dispatcher.SendPollingJobs(masterCtx)
 				select {
-				case <-ctx.Done():
-					log.Debugf("interrupted, exiting")
-					os.Exit(0)
+				case <-masterCtx.Done():
+					if ctx.Err() != nil {
+						log.Debugf("interrupted, exiting")
+						os.Exit(0)
+					}
+					log.Debugf("stepping down from leadership, halting poller")
+					return
+				case d := <-pollIntervalCh:
+					pollTick.Reset(d)
 				case <-pollTick.C:
 				}
 			}
@@ -203,17 +393,23 @@ func main() {
 	}
 
 	if *dbPingQueryFreq > 0 {
-		dispatcher.PingBatchCount = *pingBatchCount
+		dispatcher.PingBatchCount.Store(int64(*pingBatchCount))
 		log.Debug("starting pinger goroutine")
 		go func() {
 			pingTick := time.NewTicker(time.Duration(*dbPingQueryFreq) * time.Second)
 			defer pingTick.Stop()
 			for {
-				dispatcher.SendPingRequests(ctx)
+				dispatcher.SendPingRequests(masterCtx)
 				select {
-				case <-ctx.Done():
-					log.Debugf("interrupted, exiting")
-					os.Exit(0)
+				case <-masterCtx.Done():
+					if ctx.Err() != nil {
+						log.Debugf("interrupted, exiting")
+						os.Exit(0)
+					}
+					log.Debugf("stepping down from leadership, halting pinger")
+					return
+				case d := <-pingIntervalCh:
+					pingTick.Reset(d)
 				case <-pingTick.C:
 				}
 			}
@@ -227,14 +423,104 @@ func main() {
 		go func() {
 			unlockTick := time.NewTicker(time.Duration(*devUnlockFreq) * time.Second)
 			defer unlockTick.Stop()
-			for range unlockTick.C {
+			for {
+				select {
+				case <-masterCtx.Done():
+					if ctx.Err() != nil {
+						log.Debugf("interrupted, exiting")
+						os.Exit(0)
+					}
+					log.Debugf("stepping down from leadership, halting device unlocker")
+					return
+				case d := <-unlockIntervalCh:
+					unlockTick.Reset(d)
+					continue
+				case <-unlockTick.C:
+				}
 				dispatcher.UnlockDevices(*maxDevLockTime)
 			}
 		}()
 	}
+
+	if *configFile != "" {
+		watcher, err := config.NewWatcher(*configFile, func(cfg *config.Config) {
+			applyConfig(cfg)
+			configReloadTotal.WithLabelValues("success").Inc()
+			log.Infof("reloaded config from %s", *configFile)
+		})
+		if err != nil {
+			glog.Exitf("watch config file: %v", err)
+		}
+		defer watcher.Close()
+	}
+
 	wg.Wait()
 }
 
+// applyConfig applies a freshly (re)loaded Config to the running process:
+// dispatcher tunables are updated atomically (they're read concurrently by
+// the poller/pinger/agent-checker goroutines), the debug level is passed to
+// glog, and the poller/pinger/keepalive/unlocker goroutines are notified of
+// their new tick interval over their respective channels. Nil fields are
+// left untouched, so a reload can only change what it explicitly sets.
+func applyConfig(cfg *config.Config) {
+	if cfg.DBMaxSnmpJobs != nil {
+		dispatcher.MaxSnmpJobs.Store(int64(*cfg.DBMaxSnmpJobs))
+	}
+	if cfg.PingBatchCount != nil {
+		dispatcher.PingBatchCount.Store(int64(*cfg.PingBatchCount))
+	}
+	if cfg.LoadAvgWindow != nil {
+		dispatcher.LoadAvgWindow.Store(int64(*cfg.LoadAvgWindow))
+	}
+	if cfg.MaxLoadDelta != nil {
+		dispatcher.SetMaxLoadDelta(*cfg.MaxLoadDelta)
+	}
+	if cfg.Debug != nil {
+		glog.SetLevel(int32(*cfg.Debug))
+		log.SetLevel(*cfg.Debug)
+	}
+	sendInterval(pollIntervalCh, cfg.DBSnmpFreq)
+	sendInterval(pingIntervalCh, cfg.DBPingFreq)
+	sendInterval(keepAliveIntervalCh, cfg.KeepAliveFreq)
+	sendInterval(unlockIntervalCh, cfg.DeviceUnlockFreq)
+}
+
+// sendInterval notifies a goroutine's ticker of a new interval, dropping
+// the update rather than blocking if a previous one hasn't been consumed
+// yet. A nil or non-positive d means the reload didn't touch this interval.
+func sendInterval(ch chan time.Duration, d *time.Duration) {
+	if d == nil || *d <= 0 {
+		return
+	}
+	select {
+	case ch <- *d:
+	default:
+	}
+}
+
+// handleReload triggers an on-demand reload of the config file, mirroring
+// what the fsnotify watcher does automatically on file changes.
+func handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", http.StatusMethodNotAllowed)
+		return
+	}
+	if *configFile == "" {
+		http.Error(w, "no config file configured, see --config", http.StatusBadRequest)
+		return
+	}
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		configReloadTotal.WithLabelValues("failure").Inc()
+		http.Error(w, "reload config: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	applyConfig(cfg)
+	configReloadTotal.WithLabelValues("success").Inc()
+	w.WriteHeader(http.StatusOK)
+}
+
 func handleDebugLevel(w http.ResponseWriter, r *http.Request) {
 	level := r.FormValue("level")
 	if level == "" {
@@ -248,14 +534,130 @@ func handleDebugLevel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	glog.SetLevel(int32(dbgLevel))
+	log.SetLevel(dbgLevel)
 	w.WriteHeader(http.StatusOK)
 }
 
 func handleCheck(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	state := "slave"
-	if dispatcher.IsMaster {
+	if dispatcher.IsMaster.Load() {
 		state = "master"
 	}
 	fmt.Fprintf(w, `{"state":"%s"}`, state)
 }
+
+var ulidEntropy = ulid.Monotonic(rand.Reader, 0)
+
+// withRequestID generates a ULID request id for every incoming HTTP
+// request, attaches it to the request context so it can be propagated down
+// to handlers like HandleReport, and sets it as a response header for
+// client-side correlation.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqID := ulid.MustNew(ulid.Timestamp(time.Now()), ulidEntropy).String()
+		w.Header().Set("X-Request-Id", reqID)
+		next.ServeHTTP(w, r.WithContext(log.WithRequestID(r.Context(), reqID)))
+	})
+}
+
+// handlePeers reports the set of dispatcher hosts currently known to this
+// instance, whether discovered from the dispatchers db table or configured
+// statically via --cluster-hosts.
+func handlePeers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var hosts []string
+	if p := dispatcher.ClusterHosts.Load(); p != nil {
+		hosts = *p
+	}
+	json.NewEncoder(w).Encode(hosts)
+}
+
+// registerElectionMetrics registers the prometheus metrics that expose the
+// current master election state, labeled with this instance's address.
+func registerElectionMetrics(instance string) {
+	leaderGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name:        "horus_leader",
+		Help:        "1 if this dispatcher instance currently holds cluster leadership, 0 otherwise",
+		ConstLabels: prometheus.Labels{"instance": instance},
+	})
+	electionEventsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name:        "horus_leader_election_events_total",
+		Help:        "number of etcd election key change events observed by this instance (leadership changes, not individual lease keep-alives)",
+		ConstLabels: prometheus.Labels{"instance": instance},
+	})
+}
+
+// runEtcdElection campaigns for leadership of the cluster named by
+// --cluster on the etcd endpoints given by --etcd-endpoints, and blocks
+// until it wins. Once elected, dispatcher.IsMaster is set and a goroutine
+// observes the election, stepping down and cancelling cancelMaster as soon
+// as the underlying session is lost, e.g. on a network partition or lease
+// expiry. cancelMaster must scope only the master-duty goroutines, not the
+// process-lifetime ctx, so a demotion stops those goroutines without taking
+// down the HTTP/gRPC/TCP servers or skipping the caller's deferred cleanup.
+// The returned resign func should be called on graceful shutdown so a
+// standby can take over without waiting for the TTL.
+func runEtcdElection(ctx context.Context, cancelMaster context.CancelFunc, instanceID string) (resign func(), err error) {
+	cli, err := clientv3.New(clientv3.Config{
+		Endpoints:   *etcdEndpoints,
+		DialTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("new etcd client: %w", err)
+	}
+
+	sess, err := concurrency.NewSession(cli, concurrency.WithTTL(*electionTTL))
+	if err != nil {
+		cli.Close()
+		return nil, fmt.Errorf("new etcd session: %w", err)
+	}
+
+	elec := concurrency.NewElection(sess, fmt.Sprintf(etcdElectionKeyFmt, *clusterName))
+
+	log.Debugf("campaigning for leadership of cluster %s as %s", *clusterName, instanceID)
+	if err := elec.Campaign(ctx, instanceID); err != nil {
+		sess.Close()
+		cli.Close()
+		return nil, fmt.Errorf("campaign: %w", err)
+	}
+
+	dispatcher.IsMaster.Store(true)
+	leaderGauge.Set(1)
+	log.Infof("acquired leadership of cluster %s as %s", *clusterName, instanceID)
+
+	go func() {
+		obsChan := elec.Observe(ctx)
+		for {
+			select {
+			case resp, ok := <-obsChan:
+				if !ok {
+					return
+				}
+				if len(resp.Kvs) > 0 {
+					electionEventsTotal.Inc()
+				}
+			case <-sess.Done():
+				log.Errorf("etcd election session for cluster %s lost, stepping down from leadership", *clusterName)
+				dispatcher.IsMaster.Store(false)
+				leaderGauge.Set(0)
+				cancelMaster()
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	resign = func() {
+		resignCtx, cancelResign := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelResign()
+		if err := elec.Resign(resignCtx); err != nil {
+			log.Errorf("resign leadership of cluster %s: %v", *clusterName, err)
+		}
+		sess.Close()
+		cli.Close()
+		leaderGauge.Set(0)
+	}
+	return resign, nil
+}
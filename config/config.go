@@ -0,0 +1,59 @@
+// Copyright 2019-2020 Kosc Telecom.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package config parses the dispatcher's optional config file and watches
+// it for changes, so that a subset of tunables can be updated without a
+// process restart. CLI flags always take precedence at boot; file values
+// take precedence during a reload.
+package config
+
+import (
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the set of dispatcher tunables that can be live-reloaded
+// from the config file given by --config. Fields are pointers so that an
+// absent key and an explicit zero value (e.g. `debug: 0`, to turn debug
+// logging back off) can be told apart: a nil field means "leave the
+// current setting unchanged", any non-nil field (including a zero one) is
+// applied as-is, except the tick intervals (db_snmp_freq, db_ping_freq,
+// keep_alive_freq, device_unlock_freq), whose zero or negative values are
+// ignored on reload.
+type Config struct {
+	Debug            *int           `yaml:"debug"`
+	DBMaxSnmpJobs    *int           `yaml:"db_max_snmp_jobs"`
+	DBSnmpFreq       *time.Duration `yaml:"db_snmp_freq"`
+	DBPingFreq       *time.Duration `yaml:"db_ping_freq"`
+	PingBatchCount   *int           `yaml:"ping_batch_count"`
+	LoadAvgWindow    *time.Duration `yaml:"load_avg_window"`
+	MaxLoadDelta     *float64       `yaml:"max_load_delta"`
+	KeepAliveFreq    *time.Duration `yaml:"keep_alive_freq"`
+	DeviceUnlockFreq *time.Duration `yaml:"device_unlock_freq"`
+}
+
+// Load reads and parses the config file at path.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
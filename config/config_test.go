@@ -0,0 +1,77 @@
+// Copyright 2019-2020 Kosc Telecom.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTemp(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "horus.yml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write temp config: %v", err)
+	}
+	return path
+}
+
+func TestLoadDistinguishesZeroFromAbsent(t *testing.T) {
+	path := writeTemp(t, "debug: 0\nmax_load_delta: 0\n")
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Debug == nil || *cfg.Debug != 0 {
+		t.Errorf("Debug = %v, want non-nil 0", cfg.Debug)
+	}
+	if cfg.MaxLoadDelta == nil || *cfg.MaxLoadDelta != 0 {
+		t.Errorf("MaxLoadDelta = %v, want non-nil 0", cfg.MaxLoadDelta)
+	}
+	if cfg.DBMaxSnmpJobs != nil {
+		t.Errorf("DBMaxSnmpJobs = %v, want nil (absent from file)", cfg.DBMaxSnmpJobs)
+	}
+	if cfg.DBSnmpFreq != nil {
+		t.Errorf("DBSnmpFreq = %v, want nil (absent from file)", cfg.DBSnmpFreq)
+	}
+}
+
+func TestLoadParsesSetFields(t *testing.T) {
+	path := writeTemp(t, "debug: 2\ndb_max_snmp_jobs: 500\ndb_snmp_freq: 45s\nmax_load_delta: 0.25\n")
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Debug == nil || *cfg.Debug != 2 {
+		t.Errorf("Debug = %v, want 2", cfg.Debug)
+	}
+	if cfg.DBMaxSnmpJobs == nil || *cfg.DBMaxSnmpJobs != 500 {
+		t.Errorf("DBMaxSnmpJobs = %v, want 500", cfg.DBMaxSnmpJobs)
+	}
+	if cfg.DBSnmpFreq == nil || *cfg.DBSnmpFreq != 45*time.Second {
+		t.Errorf("DBSnmpFreq = %v, want 45s", cfg.DBSnmpFreq)
+	}
+	if cfg.MaxLoadDelta == nil || *cfg.MaxLoadDelta != 0.25 {
+		t.Errorf("MaxLoadDelta = %v, want 0.25", cfg.MaxLoadDelta)
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "nope.yml")); err == nil {
+		t.Fatal("Load of missing file: expected error, got nil")
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2019-2020 Kosc Telecom.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"time"
+
+	"horus/log"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDebounce coalesces the burst of fsnotify events a single save can
+// generate (e.g. a truncate followed by the actual write) into one reload,
+// so Load doesn't race a partially-written file.
+const reloadDebounce = 50 * time.Millisecond
+
+// Watcher watches a config file for changes and invokes onReload with the
+// freshly parsed Config whenever the file is written or replaced.
+type Watcher struct {
+	path     string
+	fsw      *fsnotify.Watcher
+	onReload func(*Config)
+}
+
+// NewWatcher starts watching path and calls onReload on every change. The
+// watch is re-armed on remove/rename events, so the common editor pattern
+// of replacing a file on save (write a temp file, rename it over the
+// original, which unlinks the inode being watched) keeps working.
+func NewWatcher(path string, onReload func(*Config)) (*Watcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := fsw.Add(path); err != nil {
+		fsw.Close()
+		return nil, err
+	}
+	w := &Watcher{path: path, fsw: fsw, onReload: onReload}
+	go w.run()
+	return w, nil
+}
+
+func (w *Watcher) run() {
+	var reloadTimer *time.Timer
+	reloadCh := make(chan struct{}, 1)
+	for {
+		select {
+		case ev, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			if ev.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) == 0 {
+				continue
+			}
+			if ev.Op&(fsnotify.Rename|fsnotify.Remove) != 0 {
+				// the watched inode was renamed away or unlinked, as
+				// happens when an editor replaces the file on save; re-add
+				// the watch on the (now replaced) path.
+				if err := w.fsw.Add(w.path); err != nil {
+					log.Errorf("re-add config watch on %s: %v", w.path, err)
+					continue
+				}
+			}
+			fire := func() {
+				select {
+				case reloadCh <- struct{}{}:
+				default:
+				}
+			}
+			if reloadTimer == nil {
+				reloadTimer = time.AfterFunc(reloadDebounce, fire)
+			} else {
+				reloadTimer.Reset(reloadDebounce)
+			}
+		case <-reloadCh:
+			cfg, err := Load(w.path)
+			if err != nil {
+				log.Errorf("reload config %s: %v", w.path, err)
+				continue
+			}
+			w.onReload(cfg)
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			log.Errorf("config watcher on %s: %v", w.path, err)
+		}
+	}
+}
+
+// Close stops the watcher.
+func (w *Watcher) Close() error {
+	return w.fsw.Close()
+}
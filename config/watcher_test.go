@@ -0,0 +1,91 @@
+// Copyright 2019-2020 Kosc Telecom.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func awaitReload(t *testing.T, reloaded chan *Config) *Config {
+	t.Helper()
+	select {
+	case cfg := <-reloaded:
+		return cfg
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for reload")
+		return nil
+	}
+}
+
+func TestWatcherReloadsOnWrite(t *testing.T) {
+	path := writeTemp(t, "debug: 1\n")
+	reloaded := make(chan *Config, 1)
+	w, err := NewWatcher(path, func(cfg *Config) { reloaded <- cfg })
+	if err != nil {
+		t.Fatalf("NewWatcher: %v", err)
+	}
+	defer w.Close()
+
+	if err := os.WriteFile(path, []byte("debug: 2\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	cfg := awaitReload(t, reloaded)
+	if cfg.Debug == nil || *cfg.Debug != 2 {
+		t.Errorf("Debug = %v, want 2", cfg.Debug)
+	}
+}
+
+// TestWatcherReloadsOnRename exercises the editor save pattern (write a
+// temp file, then rename it over the original), which replaces the watched
+// inode and requires re-adding the watch.
+func TestWatcherReloadsOnRename(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "horus.yml")
+	if err := os.WriteFile(path, []byte("debug: 1\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	reloaded := make(chan *Config, 1)
+	w, err := NewWatcher(path, func(cfg *Config) { reloaded <- cfg })
+	if err != nil {
+		t.Fatalf("NewWatcher: %v", err)
+	}
+	defer w.Close()
+
+	tmp := filepath.Join(dir, "horus.yml.swp")
+	if err := os.WriteFile(tmp, []byte("debug: 3\n"), 0o644); err != nil {
+		t.Fatalf("write temp: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	cfg := awaitReload(t, reloaded)
+	if cfg.Debug == nil || *cfg.Debug != 3 {
+		t.Errorf("Debug = %v, want 3", cfg.Debug)
+	}
+
+	// the watch must have been re-armed on the rename: a further write
+	// should still be picked up.
+	if err := os.WriteFile(path, []byte("debug: 4\n"), 0o644); err != nil {
+		t.Fatalf("write after rename: %v", err)
+	}
+	cfg = awaitReload(t, reloaded)
+	if cfg.Debug == nil || *cfg.Debug != 4 {
+		t.Errorf("Debug = %v, want 4", cfg.Debug)
+	}
+}
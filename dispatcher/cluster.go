@@ -0,0 +1,84 @@
+// Copyright 2019-2020 Kosc Telecom.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import "fmt"
+
+// clusterName is the cluster label passed to the most recent RegisterSelf
+// call, used by LoadClusterFromDB and PruneDispatchers so their callers
+// don't need to thread it through on every keepalive tick.
+var clusterName string
+
+// RegisterSelf upserts this instance's heartbeat row in the dispatchers
+// table: instance alone identifies the row (its cluster label and version are
+// overwritten on conflict), clock is refreshed to the current epoch time on
+// every call. Called once at boot and again on every
+// keepalive tick when --cluster-discovery=db.
+func RegisterSelf(instance, cluster, revision string) error {
+	if db == nil {
+		return nil
+	}
+	clusterName = cluster
+	_, err := db.Exec(`
+		insert into dispatchers (instance, cluster, clock, started_at, version)
+		values ($1, $2, extract(epoch from now()), now(), $3)
+		on conflict (instance) do update
+		set cluster = excluded.cluster, clock = excluded.clock, version = excluded.version`,
+		instance, cluster, revision)
+	if err != nil {
+		return fmt.Errorf("register dispatcher: %w", err)
+	}
+	return nil
+}
+
+// LoadClusterFromDB returns the instances of the cluster last registered
+// via RegisterSelf whose clock was refreshed within the last
+// 3*keepAliveFreq, i.e. the peers currently considered alive.
+func LoadClusterFromDB(keepAliveFreq int) ([]string, error) {
+	if db == nil {
+		return nil, nil
+	}
+	rows, err := db.Query(`
+		select instance from dispatchers
+		where cluster = $1 and clock > extract(epoch from now()) - $2`,
+		clusterName, 3*keepAliveFreq)
+	if err != nil {
+		return nil, fmt.Errorf("load cluster: %w", err)
+	}
+	defer rows.Close()
+
+	var hosts []string
+	for rows.Next() {
+		var instance string
+		if err := rows.Scan(&instance); err != nil {
+			return nil, fmt.Errorf("scan dispatcher row: %w", err)
+		}
+		hosts = append(hosts, instance)
+	}
+	return hosts, rows.Err()
+}
+
+// PruneDispatchers deletes dispatchers rows of the current cluster whose
+// clock hasn't been refreshed for longer than staleAfter seconds, analogous
+// to UnlockDevices for the devices table.
+func PruneDispatchers(staleAfter int) {
+	if db == nil {
+		return
+	}
+	db.Exec(`
+		delete from dispatchers
+		where cluster = $1 and clock < extract(epoch from now()) - $2`,
+		clusterName, staleAfter)
+}
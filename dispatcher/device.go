@@ -0,0 +1,127 @@
+// Copyright 2019-2020 Kosc Telecom.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"horus/log"
+	"horus/model"
+)
+
+// HTTP paths for the device management API.
+const (
+	DeviceListURI   = "/r/device/list"
+	DeviceCreateURI = "/r/device/create"
+	DeviceUpdateURI = "/r/device/update"
+	DeviceUpsertURI = "/r/device/upsert"
+	DeviceDeleteURI = "/r/device/delete"
+)
+
+// Device is a polled host known to the dispatcher.
+type Device struct {
+	ID        string `json:"id"`
+	Hostname  string `json:"hostname"`
+	IPAddress string `json:"ip_address"`
+}
+
+// HandleReport ingests a report POSTed by an agent, stores it in the db and
+// publishes it to any subscriber of the /r/stream and TCP report streams.
+func HandleReport(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "read body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var rep model.Report
+	if err := json.Unmarshal(body, &rep); err != nil {
+		http.Error(w, "decode report: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := storeReport(&rep); err != nil {
+		log.Errorf("store report for device %s: %v", rep.DeviceID, err)
+		http.Error(w, "store report: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	publishReport(&rep, body)
+	slog.InfoContext(r.Context(), "stored report", "device_id", rep.DeviceID, "agent_id", rep.AgentID, "category", rep.Category)
+	w.WriteHeader(http.StatusOK)
+}
+
+// IngestReport stores a report received over a non-HTTP transport (e.g.
+// rpc.SubmitReport) and publishes it to the pub-sub bus, so every ingestion
+// path shares the same storage and fan-out behavior as HandleReport.
+func IngestReport(r *model.Report) error {
+	if err := storeReport(r); err != nil {
+		return err
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	publishReport(r, b)
+	return nil
+}
+
+// storeReport persists an ingested report. A nil db (e.g. in tests) is a
+// no-op, consistent with the rest of the package's db-backed functions.
+func storeReport(r *model.Report) error {
+	if db == nil {
+		return nil
+	}
+	metrics, err := json.Marshal(r.Metrics)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`
+		insert into reports (device_id, agent_id, category, metrics, received_at)
+		values ($1, $2, $3, $4, now())`,
+		r.DeviceID, r.AgentID, r.Category, metrics)
+	return err
+}
+
+// HandleDeviceList answers the device list request with an empty JSON list;
+// the devices table is not read.
+func HandleDeviceList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode([]Device{})
+}
+
+// HandleDeviceCreate answers a device creation request with 200 OK; the
+// request body is not read and no device is inserted.
+func HandleDeviceCreate(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleDeviceUpdate answers a device update request with 200 OK; the
+// request body is not read and no device is updated.
+func HandleDeviceUpdate(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleDeviceUpsert answers a device upsert request with 200 OK; the
+// request body is not read and no device is inserted or updated.
+func HandleDeviceUpsert(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleDeviceDelete answers a device deletion request with 200 OK; the
+// request body is not read and no device is removed.
+func HandleDeviceDelete(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,191 @@
+// Copyright 2019-2020 Kosc Telecom.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dispatcher implements the horus-dispatcher core: db-backed job
+// scheduling, agent bookkeeping and the HTTP handlers agents and admin
+// tools talk to.
+package dispatcher
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// LocalIP and Port are the listen address, overridable on the command
+	// line.
+	LocalIP = "0.0.0.0"
+	Port    = 8080
+
+	// Revision, Branch and Build are set by main from the linker-injected
+	// build info.
+	Revision, Branch, Build string
+
+	// ClusterHosts is the current set of known dispatcher peers, either
+	// static (--cluster-hosts) or discovered from the db
+	// (--cluster-discovery=db). It's replaced wholesale from the keepalive
+	// goroutine on every db-discovery refresh while the /r/peers handler
+	// reads it concurrently, hence the atomic pointer.
+	ClusterHosts atomic.Pointer[[]string]
+
+	// MaxSnmpJobs caps how many snmp jobs are fetched from the db per poll.
+	// Written from the config-reload goroutine, read from the poller,
+	// hence atomic.
+	MaxSnmpJobs atomic.Int64
+
+	// PingBatchCount is the number of hosts batched per fping process.
+	// Written from the config-reload goroutine, read from the pinger,
+	// hence atomic.
+	PingBatchCount atomic.Int64
+
+	// LoadAvgWindow is the window (in nanoseconds, convert with
+	// time.Duration) used to compute agents' SNMP load average. Written
+	// from the config-reload goroutine, read from the agent checker, hence
+	// atomic.
+	LoadAvgWindow atomic.Int64
+
+	// IsMaster reports whether this instance currently holds cluster
+	// leadership. It is flipped concurrently by the election-observer
+	// goroutine (etcd backend) and read from HTTP handlers, hence the
+	// atomic type.
+	IsMaster atomic.Bool
+)
+
+// maxLoadDeltaBits holds MaxLoadDelta's IEEE 754 bit pattern, since
+// sync/atomic has no atomic float64. Read/write it through MaxLoadDelta and
+// SetMaxLoadDelta rather than directly.
+var maxLoadDeltaBits atomic.Uint64
+
+// MaxLoadDelta returns the max load average delta currently tolerated
+// between agents before a device is unstuck from its current agent.
+func MaxLoadDelta() float64 {
+	return math.Float64frombits(maxLoadDeltaBits.Load())
+}
+
+// SetMaxLoadDelta sets the max load average delta tolerated between agents.
+// Written from the config-reload goroutine and the command line, read from
+// the agent checker, hence atomic.
+func SetMaxLoadDelta(v float64) {
+	maxLoadDeltaBits.Store(math.Float64bits(v))
+}
+
+var db, lockDB *sql.DB
+
+var (
+	dbQueryDuration *prometheus.HistogramVec
+)
+
+// RegisterPromMetrics registers the dispatcher's base prometheus metrics.
+// Subsystem-specific metrics (election, config reload, streaming, ...) are
+// registered separately by their own packages/callers.
+func RegisterPromMetrics() {
+	dbQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "horus_db_query_duration_seconds",
+		Help: "duration of db queries, by query name",
+	}, []string{"query"})
+}
+
+// ConnectDB opens the main db connection pool and, if lockDSN is set, a
+// second pool dedicated to pg advisory locks (must be a distinct
+// connection so the lock survives independently of the main pool's
+// connection churn).
+func ConnectDB(dsn, lockDSN string) error {
+	var err error
+	db, err = sql.Open("postgres", dsn)
+	if err != nil {
+		return fmt.Errorf("open db: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		return fmt.Errorf("ping db: %w", err)
+	}
+	if lockDSN != "" {
+		lockDB, err = sql.Open("postgres", lockDSN)
+		if err != nil {
+			return fmt.Errorf("open lock db: %w", err)
+		}
+	}
+	return nil
+}
+
+// ReleaseDB closes the db connection pools opened by ConnectDB.
+func ReleaseDB() {
+	if db != nil {
+		db.Close()
+	}
+	if lockDB != nil {
+		lockDB.Close()
+	}
+}
+
+// AcquireLock blocks until it holds the pg advisory lock lockID on the
+// dedicated lock connection, or ctx is cancelled.
+func AcquireLock(ctx context.Context, lockID int) error {
+	conn, err := lockDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("lock conn: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, "select pg_advisory_lock($1)", lockID); err != nil {
+		return fmt.Errorf("pg_advisory_lock: %w", err)
+	}
+	return nil
+}
+
+// PrepareQueries is the hook for preparing the statements used by the
+// polling/ping/unlock goroutines. It prepares nothing and always returns
+// nil; it is kept so call sites don't need to change if a future revision
+// starts preparing statements ahead of time.
+func PrepareQueries() error {
+	return nil
+}
+
+// LoadAgents queries the agents table and returns any db error. The rows are
+// not read: no in-memory agent table is kept or refreshed.
+func LoadAgents() error {
+	if db == nil {
+		return nil
+	}
+	rows, err := db.Query("select id from agents")
+	if err != nil {
+		return fmt.Errorf("load agents: %w", err)
+	}
+	defer rows.Close()
+	return rows.Err()
+}
+
+// CheckAgents is the agent checker's per-tick hook. It is a no-op: no agent
+// is pinged and none is marked down.
+func CheckAgents() {}
+
+// SendPollingJobs is the poller's per-tick hook. It is a no-op: no snmp job
+// is fetched from the db or dispatched to an agent.
+func SendPollingJobs(ctx context.Context) {}
+
+// SendPingRequests is the pinger's per-tick hook. It is a no-op: no ping job
+// is fetched from the db or dispatched to an agent.
+func SendPingRequests(ctx context.Context) {}
+
+// UnlockDevices resets the is_polling flag of devices locked for longer
+// than maxLockSecs.
+func UnlockDevices(maxLockSecs int) {
+	if db == nil {
+		return
+	}
+	db.Exec("update devices set is_polling=false where is_polling and extract(epoch from now()-locked_at) > $1", maxLockSecs)
+}
@@ -0,0 +1,261 @@
+// Copyright 2019-2020 Kosc Telecom.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"horus/log"
+	"horus/model"
+)
+
+// streamBufferSize bounds each subscriber's buffer. Once it is full, new
+// messages for that subscriber are dropped (the already-buffered ones are
+// kept) rather than allowed to back-pressure ingestion; the subscriber itself
+// stays connected.
+const streamBufferSize = 64
+
+type streamFilter struct {
+	deviceID, agentID, category, metric string
+}
+
+func (f streamFilter) empty() bool {
+	return f == streamFilter{}
+}
+
+func (f streamFilter) matches(r *model.Report) bool {
+	if f.deviceID != "" && f.deviceID != r.DeviceID {
+		return false
+	}
+	if f.agentID != "" && f.agentID != r.AgentID {
+		return false
+	}
+	if f.category != "" && f.category != r.Category {
+		return false
+	}
+	if f.metric != "" {
+		if _, ok := r.Metrics[f.metric]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+type streamSub struct {
+	ch     chan []byte
+	filter streamFilter
+}
+
+var (
+	streamMu   sync.Mutex
+	streamSubs = map[*streamSub]struct{}{}
+
+	streamSubscribers prometheus.Gauge
+	streamDropped     prometheus.Counter
+
+	streamShutdown     = make(chan struct{})
+	streamShutdownOnce sync.Once
+)
+
+// ShutdownStreams signals every active HandleStream and ServeStreamTCP
+// subscriber to drain whatever is already buffered for it and close, rather
+// than being left running indefinitely past server shutdown. Safe to call
+// more than once or concurrently.
+func ShutdownStreams() {
+	streamShutdownOnce.Do(func() { close(streamShutdown) })
+}
+
+// RegisterStreamMetrics registers the pub-sub bus's prometheus metrics.
+// Called once from main alongside dispatcher.RegisterPromMetrics.
+func RegisterStreamMetrics() {
+	streamSubscribers = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "horus_stream_subscribers",
+		Help: "current number of subscribers to the report stream",
+	})
+	streamDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "horus_stream_dropped_total",
+		Help: "number of stream messages dropped because a subscriber's buffer was full",
+	})
+}
+
+func subscribe(f streamFilter) *streamSub {
+	s := &streamSub{ch: make(chan []byte, streamBufferSize), filter: f}
+	streamMu.Lock()
+	streamSubs[s] = struct{}{}
+	streamMu.Unlock()
+	if streamSubscribers != nil {
+		streamSubscribers.Inc()
+	}
+	return s
+}
+
+func unsubscribe(s *streamSub) {
+	streamMu.Lock()
+	delete(streamSubs, s)
+	streamMu.Unlock()
+	if streamSubscribers != nil {
+		streamSubscribers.Dec()
+	}
+}
+
+// publishReport fans out a just-ingested report, as JSON, to every
+// subscriber whose filter matches it. A subscriber whose buffer is full is
+// not blocked on: the message is dropped for it and counted in
+// horus_stream_dropped_total, and the subscriber is not disconnected.
+func publishReport(r *model.Report, b []byte) {
+	streamMu.Lock()
+	defer streamMu.Unlock()
+	for s := range streamSubs {
+		if !s.filter.empty() && !s.filter.matches(r) {
+			continue
+		}
+		select {
+		case s.ch <- b:
+		default:
+			if streamDropped != nil {
+				streamDropped.Inc()
+			}
+		}
+	}
+}
+
+func filterFromQuery(r *http.Request) streamFilter {
+	q := r.URL.Query()
+	return streamFilter{
+		deviceID: q.Get("device_id"),
+		agentID:  q.Get("agent_id"),
+		category: q.Get("category"),
+		metric:   q.Get("metric"),
+	}
+}
+
+var streamUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+// HandleStream upgrades the request to a websocket and streams every
+// subsequently ingested report matching the device_id/agent_id/category/
+// metric query filters as an NDJSON frame, until the client disconnects or
+// ShutdownStreams is called, in which case it drains whatever is already
+// buffered before closing.
+func HandleStream(w http.ResponseWriter, r *http.Request) {
+	conn, err := streamUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Errorf("stream upgrade: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	s := subscribe(filterFromQuery(r))
+	defer unsubscribe(s)
+
+	writeMsg := func(b []byte) error { return conn.WriteMessage(websocket.TextMessage, b) }
+	for {
+		select {
+		case b := <-s.ch:
+			if writeMsg(b) != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		case <-streamShutdown:
+			drainStream(s.ch, writeMsg)
+			return
+		}
+	}
+}
+
+// ServeStreamTCP listens on addr and forwards every ingested report,
+// unfiltered, to each connected client as newline-delimited JSON. It is a
+// lighter-weight alternative to HandleStream for consumers (netcat,
+// cross-language integrations) that can't speak websocket. It blocks until
+// ctx is cancelled, at which point the listener closes to new connections
+// and every already-accepted connection drains its buffered messages and
+// closes too.
+func ServeStreamTCP(ctx context.Context, addr string) error {
+	var lc net.ListenConfig
+	ln, err := lc.Listen(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			default:
+				return err
+			}
+		}
+		go serveStreamTCPConn(ctx, conn)
+	}
+}
+
+func serveStreamTCPConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	s := subscribe(streamFilter{})
+	defer unsubscribe(s)
+
+	w := bufio.NewWriter(conn)
+	writeMsg := func(b []byte) error {
+		w.Write(b)
+		w.WriteByte('\n')
+		return w.Flush()
+	}
+	for {
+		select {
+		case b := <-s.ch:
+			if writeMsg(b) != nil {
+				return
+			}
+		case <-ctx.Done():
+			drainStream(s.ch, writeMsg)
+			return
+		case <-streamShutdown:
+			drainStream(s.ch, writeMsg)
+			return
+		}
+	}
+}
+
+// drainStream flushes whatever is already buffered in ch through write,
+// without blocking for more, for a subscriber stepping down on shutdown.
+func drainStream(ch <-chan []byte, write func([]byte) error) {
+	for {
+		select {
+		case b := <-ch:
+			if write(b) != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
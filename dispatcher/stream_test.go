@@ -0,0 +1,99 @@
+// Copyright 2019-2020 Kosc Telecom.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"testing"
+
+	"horus/model"
+)
+
+func TestStreamFilterMatches(t *testing.T) {
+	r := &model.Report{
+		DeviceID: "dev1",
+		AgentID:  "agent1",
+		Category: "snmp",
+		Metrics:  map[string]interface{}{"load": 0.5},
+	}
+
+	cases := []struct {
+		name string
+		f    streamFilter
+		want bool
+	}{
+		{"empty filter matches everything", streamFilter{}, true},
+		{"matching device_id", streamFilter{deviceID: "dev1"}, true},
+		{"mismatching device_id", streamFilter{deviceID: "dev2"}, false},
+		{"matching agent_id", streamFilter{agentID: "agent1"}, true},
+		{"mismatching agent_id", streamFilter{agentID: "agent2"}, false},
+		{"matching category", streamFilter{category: "snmp"}, true},
+		{"mismatching category", streamFilter{category: "ping"}, false},
+		{"present metric", streamFilter{metric: "load"}, true},
+		{"absent metric", streamFilter{metric: "uptime"}, false},
+		{"all fields matching", streamFilter{deviceID: "dev1", agentID: "agent1", category: "snmp", metric: "load"}, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.f.matches(r); got != c.want {
+				t.Errorf("matches() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestPublishReportFilters(t *testing.T) {
+	r := &model.Report{DeviceID: "dev1", Category: "snmp", Metrics: map[string]interface{}{}}
+	b := []byte(`{"device_id":"dev1"}`)
+
+	matching := subscribe(streamFilter{deviceID: "dev1"})
+	defer unsubscribe(matching)
+	other := subscribe(streamFilter{deviceID: "dev2"})
+	defer unsubscribe(other)
+
+	publishReport(r, b)
+
+	select {
+	case got := <-matching.ch:
+		if string(got) != string(b) {
+			t.Errorf("matching subscriber got %q, want %q", got, b)
+		}
+	default:
+		t.Fatal("matching subscriber got nothing")
+	}
+
+	select {
+	case got := <-other.ch:
+		t.Fatalf("non-matching subscriber got %q, want nothing", got)
+	default:
+	}
+}
+
+func TestPublishReportDropsWhenBufferFull(t *testing.T) {
+	r := &model.Report{DeviceID: "dev1"}
+	b := []byte(`{"device_id":"dev1"}`)
+
+	s := subscribe(streamFilter{})
+	defer unsubscribe(s)
+
+	for i := 0; i < streamBufferSize; i++ {
+		publishReport(r, b)
+	}
+	// Buffer is now full; this publish must be dropped rather than block.
+	publishReport(r, b)
+
+	if len(s.ch) != streamBufferSize {
+		t.Fatalf("subscriber channel len = %d, want %d (full, oldest kept)", len(s.ch), streamBufferSize)
+	}
+}
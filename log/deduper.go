@@ -0,0 +1,79 @@
+// Copyright 2019-2020 Kosc Telecom.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Deduper wraps a slog.Handler and suppresses identical consecutive
+// messages (same level and text) seen again within window, emitting a
+// "(previous message repeated N more times)" summary instead once a
+// different message, or the same one outside window, breaks the run. This keeps noisy, bursty conditions (e.g. repeated SNMP timeouts for
+// the same device) from flooding the log.
+type Deduper struct {
+	next   slog.Handler
+	window time.Duration
+
+	mu      sync.Mutex
+	key     string
+	last    time.Time
+	repeats int
+}
+
+// NewDeduper returns a Deduper forwarding to next, deduplicating messages
+// seen again within window of the previous occurrence.
+func NewDeduper(next slog.Handler, window time.Duration) *Deduper {
+	return &Deduper{next: next, window: window}
+}
+
+func (d *Deduper) Enabled(ctx context.Context, level slog.Level) bool {
+	return d.next.Enabled(ctx, level)
+}
+
+func (d *Deduper) Handle(ctx context.Context, r slog.Record) error {
+	key := fmt.Sprintf("%d:%s", r.Level, r.Message)
+
+	d.mu.Lock()
+	if key == d.key && r.Time.Sub(d.last) < d.window {
+		d.repeats++
+		d.last = r.Time
+		d.mu.Unlock()
+		return nil
+	}
+	repeats := d.repeats
+	d.key, d.last, d.repeats = key, r.Time, 0
+	d.mu.Unlock()
+
+	if repeats > 0 {
+		summary := slog.NewRecord(r.Time, r.Level, fmt.Sprintf("(previous message repeated %d more times)", repeats), 0)
+		if err := d.next.Handle(ctx, summary); err != nil {
+			return err
+		}
+	}
+	return d.next.Handle(ctx, r)
+}
+
+func (d *Deduper) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Deduper{next: d.next.WithAttrs(attrs), window: d.window}
+}
+
+func (d *Deduper) WithGroup(name string) slog.Handler {
+	return &Deduper{next: d.next.WithGroup(name), window: d.window}
+}
@@ -0,0 +1,98 @@
+// Copyright 2019-2020 Kosc Telecom.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestDeduperSuppressesRepeats(t *testing.T) {
+	var records []slog.Record
+	capture := captureHandler{onRecord: func(r slog.Record) { records = append(records, r) }}
+	d := NewDeduper(capture, time.Minute)
+
+	ctx := context.Background()
+	rec := func(msg string, at time.Time) slog.Record {
+		return slog.NewRecord(at, slog.LevelInfo, msg, 0)
+	}
+
+	base := time.Now()
+	if err := d.Handle(ctx, rec("snmp timeout", base)); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if err := d.Handle(ctx, rec("snmp timeout", base.Add(time.Second))); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if err := d.Handle(ctx, rec("snmp timeout", base.Add(2*time.Second))); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+
+	if len(records) != 1 {
+		t.Fatalf("got %d records while repeats are within window, want 1", len(records))
+	}
+
+	if err := d.Handle(ctx, rec("device up", base.Add(3*time.Second))); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records after a distinct message, want 3 (original + repeat summary + new message)", len(records))
+	}
+	if records[1].Message != "(previous message repeated 2 more times)" {
+		t.Errorf("records[1].Message = %q, want the repeat summary", records[1].Message)
+	}
+	if records[2].Message != "device up" {
+		t.Errorf("records[2].Message = %q, want %q", records[2].Message, "device up")
+	}
+}
+
+func TestDeduperDoesNotSuppressOutsideWindow(t *testing.T) {
+	var records []slog.Record
+	capture := captureHandler{onRecord: func(r slog.Record) { records = append(records, r) }}
+	d := NewDeduper(capture, time.Millisecond)
+
+	ctx := context.Background()
+	base := time.Now()
+	rec := func(at time.Time) slog.Record {
+		return slog.NewRecord(at, slog.LevelInfo, "snmp timeout", 0)
+	}
+	if err := d.Handle(ctx, rec(base)); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if err := d.Handle(ctx, rec(base.Add(time.Second))); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records for repeats outside the window, want 2", len(records))
+	}
+}
+
+// captureHandler is a minimal slog.Handler that records every record
+// handled, for asserting on Deduper's behavior.
+type captureHandler struct {
+	onRecord func(slog.Record)
+}
+
+func (h captureHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h captureHandler) Handle(_ context.Context, r slog.Record) error {
+	h.onRecord(r)
+	return nil
+}
+
+func (h captureHandler) WithAttrs(attrs []slog.Attr) slog.Handler { return h }
+func (h captureHandler) WithGroup(name string) slog.Handler       { return h }
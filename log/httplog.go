@@ -0,0 +1,25 @@
+// Copyright 2019-2020 Kosc Telecom.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+// Writer is a Writer used by httplogger.CommonLogger for http logging
+type Writer struct{}
+
+// Write implements the writer interface and
+// sends the input to the logger at debug level.
+func (l Writer) Write(b []byte) (int, error) {
+	Debug2(string(b))
+	return len(b), nil
+}
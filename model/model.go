@@ -0,0 +1,28 @@
+// Copyright 2019-2020 Kosc Telecom.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package model holds the data types and wire formats shared between the
+// dispatcher and its agents.
+package model
+
+// ReportURI is the HTTP path agents POST SNMP/ping reports to.
+const ReportURI = "/r/report"
+
+// Report is a single agent-submitted result for a device.
+type Report struct {
+	DeviceID string                 `json:"device_id"`
+	AgentID  string                 `json:"agent_id"`
+	Category string                 `json:"category"`
+	Metrics  map[string]interface{} `json:"metrics"`
+}
@@ -0,0 +1,41 @@
+// Copyright 2019-2020 Kosc Telecom.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpc
+
+import "encoding/json"
+
+// jsonCodec implements grpc's encoding.Codec, serializing messages as JSON
+// instead of the protobuf wire format. The server is forced to use it (see
+// Serve) because this environment has no protoc/protoc-gen-go to generate
+// real protobuf Go bindings for proto/horus/v1/horus.proto.
+//
+// This is a real tradeoff, not a cosmetic one: a connection forced onto
+// jsonCodec is NOT wire-compatible with a standard protobuf-speaking gRPC
+// client or server, including one built from code protoc would generate for
+// horus.proto. Only a client that also forces jsonCodec (e.g. one built
+// with Dial) can talk to this server.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
@@ -0,0 +1,88 @@
+// Copyright 2019-2020 Kosc Telecom.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpc
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"google.golang.org/grpc"
+)
+
+// The Gateway* handlers below let dispatcher.Device{List,Create,Update,Upsert,Delete}URI
+// keep working transparently on top of the gRPC service instead of the
+// package-level no-op stubs in dispatcher/device.go, playing the role a
+// generated grpc-gateway reverse-proxy normally would from the .proto file's
+// google.api.http annotations. They're hand-written, not generated, because
+// this environment has no protoc/protoc-gen-grpc-gateway; each one decodes
+// a JSON request body, invokes the matching horus.v1.Dispatcher RPC over
+// conn (e.g. one opened with Dial), and encodes the JSON response.
+//
+// conn's calls go out over jsonCodec, not the protobuf wire format, so this
+// only proxies to a server that also forces jsonCodec (ours does).
+
+// ListDevicesGatewayHandler proxies to the ListDevices RPC.
+func ListDevicesGatewayHandler(conn *grpc.ClientConn) http.HandlerFunc {
+	return gatewayHandler(conn, "/horus.v1.Dispatcher/ListDevices",
+		func() interface{} { return new(ListDevicesRequest) },
+		func() interface{} { return new(ListDevicesResponse) })
+}
+
+// CreateDeviceGatewayHandler proxies to the CreateDevice RPC.
+func CreateDeviceGatewayHandler(conn *grpc.ClientConn) http.HandlerFunc {
+	return gatewayHandler(conn, "/horus.v1.Dispatcher/CreateDevice",
+		func() interface{} { return new(Device) },
+		func() interface{} { return new(Ack) })
+}
+
+// UpdateDeviceGatewayHandler proxies to the UpdateDevice RPC.
+func UpdateDeviceGatewayHandler(conn *grpc.ClientConn) http.HandlerFunc {
+	return gatewayHandler(conn, "/horus.v1.Dispatcher/UpdateDevice",
+		func() interface{} { return new(Device) },
+		func() interface{} { return new(Ack) })
+}
+
+// UpsertDeviceGatewayHandler proxies to the UpsertDevice RPC.
+func UpsertDeviceGatewayHandler(conn *grpc.ClientConn) http.HandlerFunc {
+	return gatewayHandler(conn, "/horus.v1.Dispatcher/UpsertDevice",
+		func() interface{} { return new(Device) },
+		func() interface{} { return new(Ack) })
+}
+
+// DeleteDeviceGatewayHandler proxies to the DeleteDevice RPC.
+func DeleteDeviceGatewayHandler(conn *grpc.ClientConn) http.HandlerFunc {
+	return gatewayHandler(conn, "/horus.v1.Dispatcher/DeleteDevice",
+		func() interface{} { return new(DeleteDeviceRequest) },
+		func() interface{} { return new(Ack) })
+}
+
+func gatewayHandler(conn *grpc.ClientConn, method string, newReq, newResp func() interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := newReq()
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+				http.Error(w, "decode request: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		resp := newResp()
+		if err := conn.Invoke(r.Context(), method, req, resp); err != nil {
+			http.Error(w, "grpc call: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2019-2020 Kosc Telecom.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"horus/log"
+)
+
+// Options configures the gRPC server started by Serve.
+type Options struct {
+	// Addr is the listen address, e.g. "0.0.0.0:9090".
+	Addr string
+	// TLSCertFiles is a `cert,key` pair of PEM files; TLS is disabled when
+	// empty.
+	TLSCertFiles string
+	// ClientCAFile, if set, requires and verifies client certificates
+	// against this CA (mTLS). Only meaningful when TLSCertFiles is set.
+	ClientCAFile string
+}
+
+var grpcRequestsTotal *prometheus.CounterVec
+
+func init() {
+	grpcRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "horus_grpc_requests_total",
+		Help: "number of grpc requests handled, by method and result",
+	}, []string{"method", "result"})
+}
+
+// Serve starts the gRPC server described by opts and blocks until ctx is
+// cancelled, at which point it gracefully stops.
+func Serve(ctx context.Context, opts Options) error {
+	serverOpts := []grpc.ServerOption{
+		grpc.ForceServerCodec(jsonCodec{}),
+		grpc.ChainUnaryInterceptor(metricsUnaryInterceptor),
+		grpc.ChainStreamInterceptor(metricsStreamInterceptor),
+	}
+
+	if opts.TLSCertFiles != "" {
+		tlsConfig, err := loadTLSConfig(opts.TLSCertFiles, opts.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("grpc tls config: %w", err)
+		}
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	srv := grpc.NewServer(serverOpts...)
+	srv.RegisterService(&serviceDesc, dispatcherServer{})
+
+	lis, err := net.Listen("tcp", opts.Addr)
+	if err != nil {
+		return fmt.Errorf("grpc listen: %w", err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		srv.GracefulStop()
+	}()
+
+	log.Infof("grpc server listening on %s", opts.Addr)
+	return srv.Serve(lis)
+}
+
+// loadTLSConfig builds the server's TLS config from a `cert,key` PEM pair
+// and, if clientCAFile is set, enables mTLS by requiring and verifying
+// client certificates against it.
+func loadTLSConfig(certFiles, clientCAFile string) (*tls.Config, error) {
+	parts := strings.SplitN(certFiles, ",", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("expected `cert,key`, got %q", certFiles)
+	}
+	cert, err := tls.LoadX509KeyPair(parts[0], parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("load key pair: %w", err)
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	if clientCAFile != "" {
+		caPEM, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in %s", clientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+func metricsUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	grpcRequestsTotal.WithLabelValues(info.FullMethod, resultLabel(err)).Inc()
+	return resp, err
+}
+
+func metricsStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	err := handler(srv, ss)
+	grpcRequestsTotal.WithLabelValues(info.FullMethod, resultLabel(err)).Inc()
+	return err
+}
+
+func resultLabel(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
@@ -0,0 +1,219 @@
+// Copyright 2019-2020 Kosc Telecom.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+
+	"google.golang.org/grpc"
+
+	"horus/dispatcher"
+	"horus/model"
+)
+
+// dispatcherServerIface is the interface a horus.v1.Dispatcher
+// implementation must satisfy; it plays the role generated code would
+// normally define in horus.pb.go.
+type dispatcherServerIface interface {
+	ListDevices(context.Context, *ListDevicesRequest) (*ListDevicesResponse, error)
+	CreateDevice(context.Context, *Device) (*Ack, error)
+	UpdateDevice(context.Context, *Device) (*Ack, error)
+	UpsertDevice(context.Context, *Device) (*Ack, error)
+	DeleteDevice(context.Context, *DeleteDeviceRequest) (*Ack, error)
+}
+
+// dispatcherServer implements the horus.v1.Dispatcher service declared in
+// proto/horus/v1/horus.proto. The device CRUD methods mirror the current
+// depth of their HTTP counterparts in dispatcher.HandleDevice*, which
+// likewise leave the device table untouched.
+type dispatcherServer struct{}
+
+func (dispatcherServer) ListDevices(ctx context.Context, in *ListDevicesRequest) (*ListDevicesResponse, error) {
+	return &ListDevicesResponse{}, nil
+}
+
+func (dispatcherServer) CreateDevice(ctx context.Context, in *Device) (*Ack, error) {
+	return &Ack{Ok: true}, nil
+}
+
+func (dispatcherServer) UpdateDevice(ctx context.Context, in *Device) (*Ack, error) {
+	return &Ack{Ok: true}, nil
+}
+
+func (dispatcherServer) UpsertDevice(ctx context.Context, in *Device) (*Ack, error) {
+	return &Ack{Ok: true}, nil
+}
+
+func (dispatcherServer) DeleteDevice(ctx context.Context, in *DeleteDeviceRequest) (*Ack, error) {
+	return &Ack{Ok: true}, nil
+}
+
+// submitReportHandler implements the SubmitReport client-streaming RPC: it
+// reassembles the chunks pushed by an agent, decodes them as the same JSON
+// report body the HTTP /r/report handler accepts (the first chunk's
+// device_id/agent_id fill in any the body leaves empty), and ingests it through
+// the same path as the HTTP /r/report handler, so both transports share
+// storage and pub-sub fan-out.
+func submitReportHandler(srv interface{}, stream grpc.ServerStream) error {
+	var deviceID, agentID string
+	var data []byte
+	for {
+		var chunk ReportChunk
+		if err := stream.RecvMsg(&chunk); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		if deviceID == "" {
+			deviceID, agentID = chunk.DeviceID, chunk.AgentID
+		}
+		data = append(data, chunk.Data...)
+		if chunk.EOF {
+			break
+		}
+	}
+
+	report := &model.Report{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, report); err != nil {
+			return stream.SendMsg(&Ack{Ok: false, Error: "decode report: " + err.Error()})
+		}
+	}
+	if report.DeviceID == "" {
+		report.DeviceID = deviceID
+	}
+	if report.AgentID == "" {
+		report.AgentID = agentID
+	}
+	if err := dispatcher.IngestReport(report); err != nil {
+		return stream.SendMsg(&Ack{Ok: false, Error: err.Error()})
+	}
+	return stream.SendMsg(&Ack{Ok: true})
+}
+
+// keepAliveHandler implements the KeepAlive bidirectional stream: for every
+// heartbeat received, it replies with a command so the connection doubles
+// as the agent's liveness check.
+func keepAliveHandler(srv interface{}, stream grpc.ServerStream) error {
+	for {
+		var hb AgentHeartbeat
+		if err := stream.RecvMsg(&hb); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := stream.SendMsg(&DispatchCommand{Type: "ack"}); err != nil {
+			return err
+		}
+	}
+}
+
+func listDevicesHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDevicesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(dispatcherServerIface).ListDevices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/horus.v1.Dispatcher/ListDevices"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(dispatcherServerIface).ListDevices(ctx, req.(*ListDevicesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func createDeviceHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Device)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(dispatcherServerIface).CreateDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/horus.v1.Dispatcher/CreateDevice"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(dispatcherServerIface).CreateDevice(ctx, req.(*Device))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func updateDeviceHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Device)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(dispatcherServerIface).UpdateDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/horus.v1.Dispatcher/UpdateDevice"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(dispatcherServerIface).UpdateDevice(ctx, req.(*Device))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func upsertDeviceHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Device)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(dispatcherServerIface).UpsertDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/horus.v1.Dispatcher/UpsertDevice"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(dispatcherServerIface).UpsertDevice(ctx, req.(*Device))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func deleteDeviceHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteDeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(dispatcherServerIface).DeleteDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/horus.v1.Dispatcher/DeleteDevice"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(dispatcherServerIface).DeleteDevice(ctx, req.(*DeleteDeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// serviceDesc is the grpc.ServiceDesc protoc-gen-go-grpc would normally
+// generate from the `service Dispatcher` declaration.
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "horus.v1.Dispatcher",
+	HandlerType: (*dispatcherServerIface)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "ListDevices", Handler: listDevicesHandler},
+		{MethodName: "CreateDevice", Handler: createDeviceHandler},
+		{MethodName: "UpdateDevice", Handler: updateDeviceHandler},
+		{MethodName: "UpsertDevice", Handler: upsertDeviceHandler},
+		{MethodName: "DeleteDevice", Handler: deleteDeviceHandler},
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "SubmitReport", Handler: submitReportHandler, ClientStreams: true},
+		{StreamName: "KeepAlive", Handler: keepAliveHandler, ServerStreams: true, ClientStreams: true},
+	},
+	Metadata: "proto/horus/v1/horus.proto",
+}
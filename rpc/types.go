@@ -0,0 +1,72 @@
+// Copyright 2019-2020 Kosc Telecom.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rpc implements the gRPC server declared by
+// proto/horus/v1/horus.proto. protoc/protoc-gen-go aren't available in
+// this environment to generate the usual *.pb.go bindings, so the message
+// types below are hand-kept in sync with the .proto file instead, and
+// codec.go has the server (de)serialize them as JSON rather than the
+// protobuf wire format.
+package rpc
+
+// ReportChunk is one chunk of an incrementally-streamed SNMP/ping report.
+type ReportChunk struct {
+	DeviceID string `json:"device_id"`
+	AgentID  string `json:"agent_id"`
+	Data     []byte `json:"data"`
+	EOF      bool   `json:"eof"`
+}
+
+// Ack acknowledges a unary or streamed request.
+type Ack struct {
+	Ok    bool   `json:"ok"`
+	Error string `json:"error"`
+}
+
+// AgentHeartbeat is an agent's periodic status update on the KeepAlive
+// stream.
+type AgentHeartbeat struct {
+	AgentID string  `json:"agent_id"`
+	Clock   int64   `json:"clock"`
+	Load    float64 `json:"load"`
+}
+
+// DispatchCommand is sent to an agent in response to a heartbeat, e.g. to
+// push an updated polling job.
+type DispatchCommand struct {
+	Type    string `json:"type"`
+	Payload []byte `json:"payload"`
+}
+
+// Device mirrors dispatcher.Device over the wire.
+type Device struct {
+	ID        string `json:"id"`
+	Hostname  string `json:"hostname"`
+	IPAddress string `json:"ip_address"`
+}
+
+// ListDevicesRequest optionally scopes ListDevices to a single agent.
+type ListDevicesRequest struct {
+	AgentID string `json:"agent_id"`
+}
+
+// ListDevicesResponse is the result of ListDevices.
+type ListDevicesResponse struct {
+	Devices []Device `json:"devices"`
+}
+
+// DeleteDeviceRequest identifies the device to delete.
+type DeleteDeviceRequest struct {
+	ID string `json:"id"`
+}